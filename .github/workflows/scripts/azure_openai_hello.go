@@ -4,8 +4,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
-	"regexp"
 	"strings"
 	"time"
 
@@ -14,185 +12,21 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 )
 
-// DockerfileInput represents the input for Dockerfile analysis
-type DockerfileInput struct {
-	DockerfileContent string `json:"dockerfile_content"` // Plain text content of the Dockerfile
-	ErrorMessages     string `json:"error_messages,omitempty"`
-	repoFileTree      string `json:"repo_files,omitempty"`      // String representation of the file tree
-	DockerfilePath    string `json:"dockerfile_path,omitempty"` // Path to the original Dockerfile
-}
-
-// DockerfileResult represents the analysis result
-type DockerfileResult struct {
-	FixedDockerfile string `json:"fixed_dockerfile"`
-	Analysis        string `json:"analysis"`
-}
-
-func analyzeDockerfile(client *azopenai.Client, deploymentID string, input DockerfileInput) (*DockerfileResult, error) {
-	// Create prompt for analyzing the Dockerfile
-	promptText := fmt.Sprintf(`Analyze the following Dockerfile for errors and suggest fixes:
-Dockerfile:
-%s
-`, input.DockerfileContent)
-
-	// Add error information if provided and not empty
-	if input.ErrorMessages != "" {
-		promptText += fmt.Sprintf(`
-Errors encountered when running this Dockerfile:
-%s
-`, input.ErrorMessages)
-	} else {
-		promptText += `
-No error messages were provided. Please check for potential issues in the Dockerfile.
-`
-	}
-
-	// Add repository file information if provided
-	if input.repoFileTree != "" {
-		promptText += fmt.Sprintf(`
-Repository files structure:
-%s
-`, input.repoFileTree)
-	}
-
-	promptText += `
-Please:
-1. Identify any issues in the Dockerfile
-2. Provide a fixed version of the Dockerfile
-3. Explain what changes were made and why
-
-Output the fixed Dockerfile between <<<DOCKERFILE>>> tags.`
-
-	resp, err := client.GetChatCompletions(
-		context.Background(),
-		azopenai.ChatCompletionsOptions{
-			DeploymentName: to.Ptr(deploymentID),
-			Messages: []azopenai.ChatRequestMessageClassification{
-				&azopenai.ChatRequestUserMessage{
-					Content: azopenai.NewChatRequestUserMessageContent(promptText),
-				},
-			},
-		},
-		nil,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(resp.Choices) > 0 && resp.Choices[0].Message.Content != nil {
-		content := *resp.Choices[0].Message.Content
-
-		// Extract the fixed Dockerfile from between the tags
-		// Use regex to find content between <<<DOCKERFILE>>> tags
-		re := regexp.MustCompile(`<<<DOCKERFILE>>>([\s\S]*?)<<<DOCKERFILE>>>`)
-		matches := re.FindStringSubmatch(content)
-
-		fixedDockerfile := ""
-		if len(matches) > 1 {
-			// Found the dockerfile between tags
-			fixedDockerfile = strings.TrimSpace(matches[1])
-		} else {
-			// If tags aren't found, try to extract the dockerfile content intelligently
-			// Look for multi-line dockerfile content after FROM
-			fromRe := regexp.MustCompile(`(?m)^FROM[\s\S]*?$`)
-			if fromMatches := fromRe.FindString(content); fromMatches != "" {
-				// Simple heuristic: Consider everything from the first FROM as the dockerfile
-				fixedDockerfile = fromMatches
-			} else {
-				// Fallback: use the entire content (not ideal but better than nothing)
-				fixedDockerfile = content
+// defaultAITimeout bounds how long a single AI call, retries included, may run before
+// giving up; override with --ai-timeout=<duration>.
+const defaultAITimeout = 2 * time.Minute
+
+// aiTimeoutFromArgs scans args for a --ai-timeout=<duration> flag (e.g.
+// --ai-timeout=90s) and falls back to defaultAITimeout if it's absent or unparsable.
+func aiTimeoutFromArgs(args []string) time.Duration {
+	for _, arg := range args {
+		if value, ok := strings.CutPrefix(arg, "--ai-timeout="); ok {
+			if d, err := time.ParseDuration(value); err == nil {
+				return d
 			}
 		}
-
-		return &DockerfileResult{
-			FixedDockerfile: fixedDockerfile,
-			Analysis:        content,
-		}, nil
-	}
-
-	return nil, fmt.Errorf("no response from AI model")
-}
-
-// buildDockerfile attempts to build the Docker image and returns any error output
-func buildDockerfile(dockerfilePath string) (bool, string) {
-	// First check if docker is installed and available in PATH
-	if _, err := exec.LookPath("docker"); err != nil {
-		errorMsg := "Docker executable not found in PATH. Please install Docker or ensure it's available in your PATH."
-		fmt.Println(errorMsg)
-		return false, errorMsg
-	}
-
-	cmd := exec.Command("docker", "build", "-f", dockerfilePath, "-t", "test-image:latest", ".")
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
-
-	if err != nil {
-		fmt.Println("Docker build failed with error:", err)
-		return false, outputStr
-	}
-
-	return true, outputStr
-}
-
-// iterateDockerfileBuild attempts to iteratively fix and build the Dockerfile
-func iterateDockerfileBuild(client *azopenai.Client, deploymentID string, dockerfilePath string, fileStructurePath string, maxIterations int) error {
-	fmt.Printf("Starting Dockerfile build iteration process for: %s\n", dockerfilePath)
-
-	// Read the original Dockerfile
-	dockerfileContent, err := os.ReadFile(dockerfilePath)
-	if err != nil {
-		return fmt.Errorf("error reading Dockerfile: %v", err)
-	}
-
-	// Get repository structure
-	repoStructure, err := os.ReadFile(fileStructurePath)
-	if err != nil {
-		return fmt.Errorf("error reading repository structure: %v", err)
-	}
-
-	currentDockerfile := string(dockerfileContent)
-
-	for i := 0; i < maxIterations; i++ {
-		fmt.Printf("\n=== Iteration %d of %d ===\n", i+1, maxIterations)
-
-		// Try to build
-		success, buildOutput := buildDockerfile(dockerfilePath)
-		if success {
-			fmt.Println("🎉 Docker build succeeded!")
-			return nil
-		}
-
-		fmt.Println("Docker build failed. Using AI to fix issues...")
-
-		// Prepare input for AI analysis
-		input := DockerfileInput{
-			DockerfileContent: currentDockerfile,
-			ErrorMessages:     buildOutput,
-			repoFileTree:      string(repoStructure),
-			DockerfilePath:    dockerfilePath,
-		}
-
-		// Get AI to fix the Dockerfile
-		result, err := analyzeDockerfile(client, deploymentID, input)
-		if err != nil {
-			return fmt.Errorf("error in AI analysis: %v", err)
-		}
-
-		// Update the Dockerfile
-		currentDockerfile = result.FixedDockerfile
-		fmt.Println("AI suggested fixes:")
-		fmt.Println(result.Analysis)
-
-		// Write the fixed Dockerfile
-		if err := os.WriteFile(dockerfilePath, []byte(currentDockerfile), 0644); err != nil {
-			return fmt.Errorf("error writing fixed Dockerfile: %v", err)
-		}
-
-		fmt.Printf("Updated Dockerfile written. Attempting build again...\n")
-		time.Sleep(1 * time.Second) // Small delay for readability
 	}
-
-	return fmt.Errorf("failed to fix Dockerfile after %d iterations", maxIterations)
+	return defaultAITimeout
 }
 
 func main() {
@@ -213,6 +47,7 @@ func main() {
 		fmt.Printf("Error creating Azure OpenAI client: %v\n", err)
 		os.Exit(1)
 	}
+	aiClient := &realAzureOpenAIClient{client: client}
 
 	// Check command line arguments
 	if len(os.Args) > 1 {
@@ -221,6 +56,7 @@ func main() {
 			maxIterations := 5
 			dockerfilePath := "../../../Dockerfile"
 			fileStructurePath := "repo_structure.txt" // Updated default extension
+			builderName := ""                         // empty means auto-detect
 
 			// Allow custom dockerfile path
 			if len(os.Args) > 2 {
@@ -237,15 +73,35 @@ func main() {
 				fmt.Sscanf(os.Args[4], "%d", &maxIterations)
 			}
 
-			if err := iterateDockerfileBuild(client, deploymentID, dockerfilePath, fileStructurePath, maxIterations); err != nil {
+			// Allow an explicit build backend: docker, buildx, podman, or buildah
+			if len(os.Args) > 5 {
+				builderName = os.Args[5]
+			}
+
+			var builder Builder
+			if builderName != "" {
+				builder, err = newBuilder(builderName)
+			} else {
+				builder, err = detectBuilder()
+			}
+			if err != nil {
+				fmt.Printf("Error selecting build backend: %v\n", err)
+				os.Exit(1)
+			}
+
+			aiTimeout := aiTimeoutFromArgs(os.Args)
+			budget := tokenBudgetFromArgs(os.Args, deploymentID)
+
+			if err := iterateDockerfileBuild(aiClient, deploymentID, builder, dockerfilePath, fileStructurePath, maxIterations, aiTimeout, budget); err != nil {
 				fmt.Printf("Error in dockerfile iteration process: %v\n", err)
 				os.Exit(1)
 			}
 
 		default:
 			// Default behavior - test Azure OpenAI
-			resp, err := client.GetChatCompletions(
+			resp, err := getChatCompletionsWithRetry(
 				context.Background(),
+				aiClient,
 				azopenai.ChatCompletionsOptions{
 					DeploymentName: to.Ptr(deploymentID),
 					Messages: []azopenai.ChatRequestMessageClassification{
@@ -254,7 +110,7 @@ func main() {
 						},
 					},
 				},
-				nil,
+				aiTimeoutFromArgs(os.Args),
 			)
 			if err != nil {
 				fmt.Printf("Error getting chat completions: %v\n", err)
@@ -272,5 +128,9 @@ func main() {
 	// If no arguments provided, print usage
 	fmt.Println("Usage:")
 	fmt.Println("  go run azure_openai_hello.go                          - Test Azure OpenAI connection")
-	fmt.Println("  go run azure_openai_hello.go iterate-dockerfile-build [dockerfile-path] [file-structure-path] [max-iterations] - Iteratively build and fix a Dockerfile")
+	fmt.Println("  go run azure_openai_hello.go iterate-dockerfile-build [dockerfile-path] [file-structure-path] [max-iterations] [builder] - Iteratively build and fix a Dockerfile")
+	fmt.Println("    builder: docker, buildx, podman, or buildah (default: auto-detected)")
+	fmt.Println("  Flags: --ai-timeout=<duration> caps the time per AI call, retries included (default: 2m)")
+	fmt.Println("         --max-tokens=<int> and --max-usd=<float> abort the run once the cumulative")
+	fmt.Println("         token or estimated cost budget for the run is exceeded (default: unlimited)")
 }
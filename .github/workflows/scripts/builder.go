@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// BuildResult carries the outcome of a single Builder.Build call.
+type BuildResult struct {
+	Success  bool
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Errors   []string // error lines extracted from Stderr, if the build failed
+}
+
+// Builder runs a container image build from a Dockerfile against a build context.
+type Builder interface {
+	// Name identifies the builder for logging (e.g. "docker", "buildx", "podman", "buildah").
+	Name() string
+	// Build runs the build and reports the outcome. It never returns a non-nil error for
+	// a plain build failure; a non-nil error means the builder itself could not be invoked.
+	Build(ctx context.Context, dockerfilePath, contextDir string) (BuildResult, error)
+}
+
+// dockerBuilder shells out to the classic `docker build`.
+type dockerBuilder struct{}
+
+func (dockerBuilder) Name() string { return "docker" }
+
+func (dockerBuilder) Build(ctx context.Context, dockerfilePath, contextDir string) (BuildResult, error) {
+	return runBuildCommand(ctx, "docker", "build", "-f", dockerfilePath, "-t", "test-image:latest", contextDir)
+}
+
+// buildxBuilder shells out to `docker buildx build`, which uses the BuildKit backend.
+type buildxBuilder struct{}
+
+func (buildxBuilder) Name() string { return "buildx" }
+
+func (buildxBuilder) Build(ctx context.Context, dockerfilePath, contextDir string) (BuildResult, error) {
+	return runBuildCommand(ctx, "docker", "buildx", "build", "-f", dockerfilePath, "-t", "test-image:latest", contextDir)
+}
+
+// RuleCheckWarning is a single Dockerfile lint warning as reported by
+// `docker buildx build --check` (e.g. MaintainerDeprecated, UndefinedArg).
+type RuleCheckWarning struct {
+	RuleName    string `json:"ruleName"`
+	Description string `json:"description"`
+	URL         string `json:"url,omitempty"`
+	Detail      string `json:"detail"`
+	Line        int    `json:"line"`
+}
+
+// buildxMetadata mirrors the subset of `docker buildx build --metadata-file` output
+// this tool cares about: rule-check warnings and the source line mapping BuildKit
+// attaches to the Dockerfile it built.
+type buildxMetadata struct {
+	Warnings           []RuleCheckWarning `json:"buildx.build.warnings,omitempty"`
+	DockerfileMappings map[int]string     `json:"dockerfile.mappings,omitempty"`
+}
+
+// RuleChecker is implemented by builders that can report BuildKit rule-check
+// warnings and dockerfile line mappings in addition to a plain build result.
+type RuleChecker interface {
+	CheckWarnings(ctx context.Context, dockerfilePath, contextDir string) ([]RuleCheckWarning, map[int]string, error)
+}
+
+// CheckWarnings runs `docker buildx build --check` against dockerfilePath and parses
+// the rule-check warnings and line mappings out of the resulting metadata file. It
+// does not treat warnings as fatal: `--check` exits non-zero when warnings are
+// present, so a non-zero exit here is not itself an error.
+func (buildxBuilder) CheckWarnings(ctx context.Context, dockerfilePath, contextDir string) ([]RuleCheckWarning, map[int]string, error) {
+	metadataFile, err := os.CreateTemp("", "buildx-metadata-*.json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating metadata file: %w", err)
+	}
+	metadataPath := metadataFile.Name()
+	metadataFile.Close()
+	defer os.Remove(metadataPath)
+
+	cmd := exec.CommandContext(ctx, "docker", "buildx", "build", "--check", "--metadata-file", metadataPath, "-f", dockerfilePath, contextDir)
+	_ = cmd.Run()
+
+	raw, err := os.ReadFile(metadataPath)
+	if err != nil || len(raw) == 0 {
+		return nil, nil, nil
+	}
+
+	var meta buildxMetadata
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return nil, nil, fmt.Errorf("parsing buildx metadata: %w", err)
+	}
+
+	return meta.Warnings, meta.DockerfileMappings, nil
+}
+
+// podmanBuilder shells out to `podman build`, for rootless/daemonless environments.
+type podmanBuilder struct{}
+
+func (podmanBuilder) Name() string { return "podman" }
+
+func (podmanBuilder) Build(ctx context.Context, dockerfilePath, contextDir string) (BuildResult, error) {
+	return runBuildCommand(ctx, "podman", "build", "-f", dockerfilePath, "-t", "test-image:latest", contextDir)
+}
+
+// buildahBuilder shells out to `buildah bud`.
+type buildahBuilder struct{}
+
+func (buildahBuilder) Name() string { return "buildah" }
+
+func (buildahBuilder) Build(ctx context.Context, dockerfilePath, contextDir string) (BuildResult, error) {
+	return runBuildCommand(ctx, "buildah", "bud", "-f", dockerfilePath, "-t", "test-image:latest", contextDir)
+}
+
+// runBuildCommand executes a build command and translates the result into a BuildResult.
+func runBuildCommand(ctx context.Context, name string, args ...string) (BuildResult, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	stdout, err := cmd.Output()
+
+	result := BuildResult{Stdout: string(stdout)}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		result.Stderr = string(exitErr.Stderr)
+		result.ExitCode = exitErr.ExitCode()
+		result.Success = false
+		result.Errors = extractErrorLines(result.Stderr)
+		return result, nil
+	}
+	if err != nil {
+		return BuildResult{}, fmt.Errorf("running %s: %w", name, err)
+	}
+
+	result.Success = true
+	return result, nil
+}
+
+// extractErrorLines pulls the lines from a build command's stderr that actually carry
+// an error message (e.g. docker/buildx's "ERROR: failed to solve: ...", podman/buildah's
+// "Error: ..."), so callers get a short structured list instead of having to grep the
+// raw log themselves.
+func extractErrorLines(stderr string) []string {
+	var errorLines []string
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.Contains(strings.ToLower(line), "error") {
+			errorLines = append(errorLines, line)
+		}
+	}
+	return errorLines
+}
+
+// builderNames lists the supported backends in the order auto-detection prefers them:
+// buildx (BuildKit) first for its richer diagnostics, then classic docker, then the
+// daemonless alternatives.
+var builderNames = []string{"buildx", "docker", "podman", "buildah"}
+
+// newBuilder constructs the Builder for name ("docker", "buildx", "podman", or "buildah").
+func newBuilder(name string) (Builder, error) {
+	switch name {
+	case "docker":
+		return dockerBuilder{}, nil
+	case "buildx":
+		return buildxBuilder{}, nil
+	case "podman":
+		return podmanBuilder{}, nil
+	case "buildah":
+		return buildahBuilder{}, nil
+	default:
+		return nil, fmt.Errorf("unknown builder %q", name)
+	}
+}
+
+// detectBuilder picks the first available backend by probing LookPath in builderNames
+// order. buildx availability is checked via the `docker buildx version` subcommand
+// rather than a separate binary.
+func detectBuilder() (Builder, error) {
+	if _, err := exec.LookPath("docker"); err == nil {
+		if exec.Command("docker", "buildx", "version").Run() == nil {
+			return buildxBuilder{}, nil
+		}
+		return dockerBuilder{}, nil
+	}
+	if _, err := exec.LookPath("podman"); err == nil {
+		return podmanBuilder{}, nil
+	}
+	if _, err := exec.LookPath("buildah"); err == nil {
+		return buildahBuilder{}, nil
+	}
+	return nil, fmt.Errorf("no supported build backend found in PATH (tried docker, podman, buildah)")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractErrorLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   []string
+	}{
+		{
+			name:   "buildx solve error",
+			stderr: "#5 ERROR: failed to solve: process \"/bin/sh -c exit 1\" did not complete successfully\n------\n > [3/3] RUN exit 1:\n------\n",
+			want:   []string{"#5 ERROR: failed to solve: process \"/bin/sh -c exit 1\" did not complete successfully"},
+		},
+		{
+			name:   "podman error",
+			stderr: "STEP 2/3: RUN exit 1\nError: building at STEP \"RUN exit 1\": exit status 1\n",
+			want:   []string{"Error: building at STEP \"RUN exit 1\": exit status 1"},
+		},
+		{
+			name:   "no error lines",
+			stderr: "Sending build context to Docker daemon\nStep 1/1 : FROM scratch\n",
+			want:   nil,
+		},
+		{
+			name:   "empty stderr",
+			stderr: "",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractErrorLines(tt.stderr)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("extractErrorLines() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// DockerfileInput represents the input for Dockerfile analysis
+type DockerfileInput struct {
+	DockerfileContent string `json:"dockerfile_content"` // Plain text content of the Dockerfile
+	ErrorMessages     string `json:"error_messages,omitempty"`
+	repoFileTree      string `json:"repo_files,omitempty"`      // String representation of the file tree
+	DockerfilePath    string `json:"dockerfile_path,omitempty"` // Path to the original Dockerfile
+
+	// Warnings and LineMappings come from a BuildKit rule-check (docker buildx build
+	// --check) of DockerfileContent, when the active builder supports it. They let
+	// analyzeDockerfile give the model precise, line-anchored feedback.
+	Warnings     []RuleCheckWarning `json:"warnings,omitempty"`
+	LineMappings map[int]string     `json:"line_mappings,omitempty"`
+}
+
+// DockerfileResult represents the analysis result
+type DockerfileResult struct {
+	FixedDockerfile string `json:"fixed_dockerfile"`
+	Analysis        string `json:"analysis"`
+}
+
+// ValidateDockerfile parses dockerfileContent with the same frontend parser BuildKit
+// uses and makes sure the result has at least one FROM instruction. It returns the
+// parsed AST so callers can inspect stages without re-parsing, or an error describing
+// the first syntax or instruction problem found.
+func ValidateDockerfile(dockerfileContent []byte) (*parser.Result, error) {
+	result, err := parser.Parse(bytes.NewReader(dockerfileContent))
+	if err != nil {
+		return nil, fmt.Errorf("dockerfile syntax error: %w", err)
+	}
+
+	stages, _, err := instructions.Parse(result.AST, nil)
+	if err != nil {
+		return nil, fmt.Errorf("dockerfile instruction error: %w", err)
+	}
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("dockerfile has no FROM instruction")
+	}
+
+	return result, nil
+}
+
+// describeStages renders the stage names and base images from a parsed Dockerfile as a
+// short, structured summary suitable for inclusion in a prompt.
+func describeStages(result *parser.Result) string {
+	stages, _, err := instructions.Parse(result.AST, nil)
+	if err != nil || len(stages) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for i, stage := range stages {
+		name := stage.Name
+		if name == "" {
+			name = fmt.Sprintf("%d", i)
+		}
+		fmt.Fprintf(&b, "- stage %s: FROM %s\n", name, stage.BaseName)
+	}
+	return b.String()
+}
+
+// renderLineAnnotations merges rule-check warnings and build-error line mappings into
+// a sorted, line-anchored block like "L12: MaintainerDeprecated" / "L20: build error:
+// no such file". It returns "" if there's nothing to show.
+func renderLineAnnotations(warnings []RuleCheckWarning, lineMappings map[int]string) string {
+	if len(warnings) == 0 && len(lineMappings) == 0 {
+		return ""
+	}
+
+	byLine := make(map[int][]string)
+	for _, w := range warnings {
+		byLine[w.Line] = append(byLine[w.Line], w.RuleName)
+	}
+	for line, detail := range lineMappings {
+		byLine[line] = append(byLine[line], fmt.Sprintf("build error: %s", detail))
+	}
+
+	lines := make([]int, 0, len(byLine))
+	for line := range byLine {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	var b strings.Builder
+	for _, line := range lines {
+		fmt.Fprintf(&b, "L%d: %s\n", line, strings.Join(byLine[line], "; "))
+	}
+	return b.String()
+}
+
+// chatCompletionsStream is the minimal interface analyzeDockerfile needs from a
+// streaming chat completion. It exists so tests can substitute a fake reader instead
+// of depending on the concrete SDK stream type.
+type chatCompletionsStream interface {
+	Read() (azopenai.ChatCompletions, error)
+	Close() error
+}
+
+// AzureOpenAIClient is the subset of *azopenai.Client this tool calls: analyzeDockerfile
+// uses the streaming method, main's connection test the non-streaming one.
+// It exists so analyzeDockerfile can be unit tested without a live Azure OpenAI
+// endpoint; wrap a real *azopenai.Client with realAzureOpenAIClient to satisfy it.
+type AzureOpenAIClient interface {
+	GetChatCompletions(ctx context.Context, body azopenai.ChatCompletionsOptions, options *azopenai.GetChatCompletionsOptions) (azopenai.GetChatCompletionsResponse, error)
+	GetChatCompletionsStream(ctx context.Context, body azopenai.ChatCompletionsStreamOptions, options *azopenai.GetChatCompletionsStreamOptions) (chatCompletionsStream, error)
+}
+
+// realAzureOpenAIClient adapts *azopenai.Client to AzureOpenAIClient, converting the
+// SDK's concrete streaming response into the minimal chatCompletionsStream interface.
+type realAzureOpenAIClient struct {
+	client *azopenai.Client
+}
+
+func (r *realAzureOpenAIClient) GetChatCompletions(ctx context.Context, body azopenai.ChatCompletionsOptions, options *azopenai.GetChatCompletionsOptions) (azopenai.GetChatCompletionsResponse, error) {
+	return r.client.GetChatCompletions(ctx, body, options)
+}
+
+func (r *realAzureOpenAIClient) GetChatCompletionsStream(ctx context.Context, body azopenai.ChatCompletionsStreamOptions, options *azopenai.GetChatCompletionsStreamOptions) (chatCompletionsStream, error) {
+	resp, err := r.client.GetChatCompletionsStream(ctx, body, options)
+	if err != nil {
+		return nil, err
+	}
+	return resp.ChatCompletionsStream, nil
+}
+
+// extractDockerfileResult pulls the fixed Dockerfile out of the model's full response
+// text, preferring the content between <<<DOCKERFILE>>> tags and falling back to
+// whatever follows the first FROM instruction.
+func extractDockerfileResult(content string) *DockerfileResult {
+	// Use regex to find content between <<<DOCKERFILE>>> tags
+	re := regexp.MustCompile(`<<<DOCKERFILE>>>([\s\S]*?)<<<DOCKERFILE>>>`)
+	matches := re.FindStringSubmatch(content)
+
+	fixedDockerfile := ""
+	if len(matches) > 1 {
+		// Found the dockerfile between tags
+		fixedDockerfile = strings.TrimSpace(matches[1])
+	} else {
+		// If tags aren't found, try to extract the dockerfile content intelligently
+		// Look for multi-line dockerfile content after FROM
+		fromRe := regexp.MustCompile(`(?m)^FROM[\s\S]*`)
+		if fromMatches := fromRe.FindString(content); fromMatches != "" {
+			// Simple heuristic: Consider everything from the first FROM as the dockerfile
+			fixedDockerfile = fromMatches
+		} else {
+			// Fallback: use the entire content (not ideal but better than nothing)
+			fixedDockerfile = content
+		}
+	}
+
+	return &DockerfileResult{
+		FixedDockerfile: fixedDockerfile,
+		Analysis:        content,
+	}
+}
+
+func analyzeDockerfile(client AzureOpenAIClient, deploymentID string, input DockerfileInput, aiTimeout time.Duration, budget *TokenBudget) (*DockerfileResult, error) {
+	// Create prompt for analyzing the Dockerfile
+	promptText := fmt.Sprintf(`Analyze the following Dockerfile for errors and suggest fixes:
+Dockerfile:
+%s
+`, input.DockerfileContent)
+
+	// If the current Dockerfile parses cleanly, describe its stages structurally
+	// instead of relying on the model to re-derive them from raw text.
+	if result, err := ValidateDockerfile([]byte(input.DockerfileContent)); err == nil {
+		if stages := describeStages(result); stages != "" {
+			promptText += fmt.Sprintf(`
+Parsed stages:
+%s
+`, stages)
+		}
+	}
+
+	// Add BuildKit rule-check warnings and error line mappings, annotated by line
+	// number, so the model gets precise feedback instead of a raw log dump.
+	if annotations := renderLineAnnotations(input.Warnings, input.LineMappings); annotations != "" {
+		promptText += fmt.Sprintf(`
+Line-anchored build feedback:
+%s
+`, annotations)
+	}
+
+	// Add error information if provided and not empty
+	if input.ErrorMessages != "" {
+		promptText += fmt.Sprintf(`
+Errors encountered when running this Dockerfile:
+%s
+`, input.ErrorMessages)
+	} else {
+		promptText += `
+No error messages were provided. Please check for potential issues in the Dockerfile.
+`
+	}
+
+	// Add repository file information if provided
+	if input.repoFileTree != "" {
+		promptText += fmt.Sprintf(`
+Repository files structure:
+%s
+`, input.repoFileTree)
+	}
+
+	promptText += `
+Please:
+1. Identify any issues in the Dockerfile
+2. Provide a fixed version of the Dockerfile
+3. Explain what changes were made and why
+
+Output the fixed Dockerfile between <<<DOCKERFILE>>> tags.`
+
+	ctx, cancel := context.WithTimeout(context.Background(), aiTimeout)
+	defer cancel()
+
+	stream, err := getChatCompletionsStreamWithRetry(
+		ctx,
+		client,
+		azopenai.ChatCompletionsStreamOptions{
+			DeploymentName: to.Ptr(deploymentID),
+			Messages: []azopenai.ChatRequestMessageClassification{
+				&azopenai.ChatRequestUserMessage{
+					Content: azopenai.NewChatRequestUserMessageContent(promptText),
+				},
+			},
+			StreamOptions: &azopenai.ChatCompletionStreamOptions{
+				IncludeUsage: to.Ptr(true),
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var content strings.Builder
+	var promptTokens, completionTokens int
+	for {
+		chunk, err := stream.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading AI response stream: %w", err)
+		}
+
+		if chunk.Usage != nil {
+			if chunk.Usage.PromptTokens != nil {
+				promptTokens = int(*chunk.Usage.PromptTokens)
+			}
+			if chunk.Usage.CompletionTokens != nil {
+				completionTokens = int(*chunk.Usage.CompletionTokens)
+			}
+		}
+
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta != nil && chunk.Choices[0].Delta.Content != nil {
+			piece := *chunk.Choices[0].Delta.Content
+			fmt.Print(piece)
+			content.WriteString(piece)
+		}
+	}
+	fmt.Println()
+
+	if budget != nil {
+		budget.Add(promptTokens, completionTokens)
+		if err := budget.CheckLimits(); err != nil {
+			return nil, err
+		}
+	}
+
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("no response from AI model")
+	}
+
+	return extractDockerfileResult(content.String()), nil
+}
+
+// maxValidationRetries bounds how many times in a row iterateDockerfileBuild will
+// re-prompt the model after it returns a Dockerfile that fails ValidateDockerfile,
+// before giving up on that iteration and keeping the previous known-good Dockerfile.
+const maxValidationRetries = 2
+
+// iterateDockerfileBuild attempts to iteratively fix and build the Dockerfile
+func iterateDockerfileBuild(client AzureOpenAIClient, deploymentID string, builder Builder, dockerfilePath string, fileStructurePath string, maxIterations int, aiTimeout time.Duration, budget *TokenBudget) error {
+	fmt.Printf("Starting Dockerfile build iteration process for: %s (using %s)\n", dockerfilePath, builder.Name())
+
+	// Read the original Dockerfile
+	dockerfileContent, err := os.ReadFile(dockerfilePath)
+	if err != nil {
+		return fmt.Errorf("error reading Dockerfile: %v", err)
+	}
+
+	// Get repository structure
+	repoStructure, err := os.ReadFile(fileStructurePath)
+	if err != nil {
+		return fmt.Errorf("error reading repository structure: %v", err)
+	}
+
+	currentDockerfile := string(dockerfileContent)
+
+	for i := 0; i < maxIterations; i++ {
+		fmt.Printf("\n=== Iteration %d of %d ===\n", i+1, maxIterations)
+
+		// Try to build
+		buildResult, err := builder.Build(context.Background(), dockerfilePath, filepath.Dir(dockerfilePath))
+		if err != nil {
+			return fmt.Errorf("error invoking %s builder: %v", builder.Name(), err)
+		}
+		if buildResult.Success {
+			fmt.Println("🎉 Docker build succeeded!")
+			fmt.Println(budget.Summary())
+			return nil
+		}
+
+		fmt.Printf("%s build failed. Using AI to fix issues...\n", builder.Name())
+
+		// Prepare input for AI analysis
+		errorMessages := buildResult.Stdout + buildResult.Stderr
+		if len(buildResult.Errors) > 0 {
+			errorMessages = fmt.Sprintf("Key errors:\n%s\n\nFull build output:\n%s", strings.Join(buildResult.Errors, "\n"), errorMessages)
+		}
+		input := DockerfileInput{
+			DockerfileContent: currentDockerfile,
+			ErrorMessages:     errorMessages,
+			repoFileTree:      string(repoStructure),
+			DockerfilePath:    dockerfilePath,
+		}
+
+		// If the builder can run a BuildKit rule-check, feed its warnings and line
+		// mappings into the prompt alongside the raw build log.
+		if checker, ok := builder.(RuleChecker); ok {
+			warnings, lineMappings, err := checker.CheckWarnings(context.Background(), dockerfilePath, filepath.Dir(dockerfilePath))
+			if err != nil {
+				fmt.Printf("warning: rule-check failed: %v\n", err)
+			} else {
+				input.Warnings = warnings
+				input.LineMappings = lineMappings
+			}
+		}
+
+		var fixedDockerfile string
+		var analysis string
+
+		for attempt := 0; ; attempt++ {
+			result, err := analyzeDockerfile(client, deploymentID, input, aiTimeout, budget)
+			if err != nil {
+				return fmt.Errorf("error in AI analysis (%s): %v", budget.Summary(), err)
+			}
+
+			if _, validateErr := ValidateDockerfile([]byte(result.FixedDockerfile)); validateErr != nil {
+				if attempt >= maxValidationRetries {
+					fmt.Printf("AI response failed validation %d times (%v); keeping previous Dockerfile\n", attempt+1, validateErr)
+					fixedDockerfile = ""
+					break
+				}
+				fmt.Printf("AI response failed validation (%v); re-prompting with parser error\n", validateErr)
+				input.ErrorMessages = fmt.Sprintf("%s\n\nThe previously suggested Dockerfile was rejected: %v", input.ErrorMessages, validateErr)
+				continue
+			}
+
+			fixedDockerfile = result.FixedDockerfile
+			analysis = result.Analysis
+			break
+		}
+
+		if fixedDockerfile == "" {
+			// Persistent parse failures: keep building with the last known-good
+			// Dockerfile rather than clobbering it with an invalid suggestion.
+			time.Sleep(1 * time.Second)
+			continue
+		}
+
+		// Update the Dockerfile
+		currentDockerfile = fixedDockerfile
+		fmt.Println("AI suggested fixes:")
+		fmt.Println(analysis)
+
+		// Write the fixed Dockerfile
+		if err := os.WriteFile(dockerfilePath, []byte(currentDockerfile), 0644); err != nil {
+			return fmt.Errorf("error writing fixed Dockerfile: %v", err)
+		}
+
+		fmt.Printf("Updated Dockerfile written. Attempting build again...\n")
+		time.Sleep(1 * time.Second) // Small delay for readability
+	}
+
+	return fmt.Errorf("failed to fix Dockerfile after %d iterations (%s)", maxIterations, budget.Summary())
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
+)
+
+func TestAnalyzeDockerfile(t *testing.T) {
+	tests := []struct {
+		name        string
+		client      *mockAzureOpenAIClient
+		wantFixed   string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name: "well-formed tags",
+			client: &mockAzureOpenAIClient{
+				GetChatCompletionsStreamFunc: func(ctx context.Context, body azopenai.ChatCompletionsStreamOptions, options *azopenai.GetChatCompletionsStreamOptions) (chatCompletionsStream, error) {
+					return &fakeChatCompletionsStream{chunks: []azopenai.ChatCompletions{
+						deltaChunk("Here is the fix.\n<<<DOCKERFILE>>>\n"),
+						deltaChunk("FROM golang:1.23\nCMD [\"true\"]\n<<<DOCKERFILE>>>\nDone."),
+						usageChunk(42, 17),
+					}}, nil
+				},
+			},
+			wantFixed: "FROM golang:1.23\nCMD [\"true\"]",
+		},
+		{
+			name: "fallback to FROM block when tags are missing",
+			client: &mockAzureOpenAIClient{
+				GetChatCompletionsStreamFunc: func(ctx context.Context, body azopenai.ChatCompletionsStreamOptions, options *azopenai.GetChatCompletionsStreamOptions) (chatCompletionsStream, error) {
+					return &fakeChatCompletionsStream{chunks: []azopenai.ChatCompletions{
+						deltaChunk("You should use:\nFROM golang:1.23\nCMD [\"true\"]"),
+					}}, nil
+				},
+			},
+			wantFixed: "FROM golang:1.23\nCMD [\"true\"]",
+		},
+		{
+			name: "empty stream",
+			client: &mockAzureOpenAIClient{
+				GetChatCompletionsStreamFunc: func(ctx context.Context, body azopenai.ChatCompletionsStreamOptions, options *azopenai.GetChatCompletionsStreamOptions) (chatCompletionsStream, error) {
+					return &fakeChatCompletionsStream{}, nil
+				},
+			},
+			wantErr:     true,
+			errContains: "no response from AI model",
+		},
+		{
+			name: "transport error",
+			client: &mockAzureOpenAIClient{
+				GetChatCompletionsStreamFunc: func(ctx context.Context, body azopenai.ChatCompletionsStreamOptions, options *azopenai.GetChatCompletionsStreamOptions) (chatCompletionsStream, error) {
+					return nil, errors.New("connection reset")
+				},
+			},
+			wantErr:     true,
+			errContains: "connection reset",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := &TokenBudget{DeploymentID: "o3-mini"}
+			result, err := analyzeDockerfile(tt.client, "o3-mini", DockerfileInput{DockerfileContent: "FROM scratch"}, 50*time.Millisecond, budget)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("expected error to contain %q, got %q", tt.errContains, err.Error())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.FixedDockerfile != tt.wantFixed {
+				t.Fatalf("FixedDockerfile = %q, want %q", result.FixedDockerfile, tt.wantFixed)
+			}
+		})
+	}
+}
+
+func TestAnalyzeDockerfile_TokenBudgetExceeded(t *testing.T) {
+	client := &mockAzureOpenAIClient{
+		GetChatCompletionsStreamFunc: func(ctx context.Context, body azopenai.ChatCompletionsStreamOptions, options *azopenai.GetChatCompletionsStreamOptions) (chatCompletionsStream, error) {
+			return &fakeChatCompletionsStream{chunks: []azopenai.ChatCompletions{
+				deltaChunk("<<<DOCKERFILE>>>\nFROM golang:1.23\n<<<DOCKERFILE>>>"),
+				usageChunk(1000, 1000),
+			}}, nil
+		},
+	}
+
+	budget := &TokenBudget{DeploymentID: "o3-mini", MaxTokens: 100}
+	_, err := analyzeDockerfile(client, "o3-mini", DockerfileInput{DockerfileContent: "FROM scratch"}, time.Second, budget)
+	if err == nil {
+		t.Fatal("expected token budget error, got none")
+	}
+	if !strings.Contains(err.Error(), "token budget exceeded") {
+		t.Fatalf("expected token budget error, got %q", err.Error())
+	}
+}
+
+func TestIterateDockerfileBuild_SucceedsAfterRetries(t *testing.T) {
+	dockerfilePath := t.TempDir() + "/Dockerfile"
+	if err := os.WriteFile(dockerfilePath, []byte("FROM scratch\n"), 0644); err != nil {
+		t.Fatalf("writing Dockerfile: %v", err)
+	}
+
+	structurePath := t.TempDir() + "/repo_structure.txt"
+	if err := os.WriteFile(structurePath, []byte("Dockerfile\n"), 0644); err != nil {
+		t.Fatalf("writing repo structure: %v", err)
+	}
+
+	builder := &mockBuilder{
+		name: "mock",
+		Results: []BuildResult{
+			{Success: false, Stderr: "no such file"},
+			{Success: false, Stderr: "still broken"},
+			{Success: true},
+		},
+	}
+
+	client := &mockAzureOpenAIClient{
+		GetChatCompletionsStreamFunc: func(ctx context.Context, body azopenai.ChatCompletionsStreamOptions, options *azopenai.GetChatCompletionsStreamOptions) (chatCompletionsStream, error) {
+			return &fakeChatCompletionsStream{chunks: []azopenai.ChatCompletions{
+				deltaChunk("<<<DOCKERFILE>>>\nFROM golang:1.23\n<<<DOCKERFILE>>>"),
+				usageChunk(10, 5),
+			}}, nil
+		},
+	}
+
+	budget := &TokenBudget{DeploymentID: "o3-mini"}
+	err := iterateDockerfileBuild(client, "o3-mini", builder, dockerfilePath, structurePath, 5, 2*time.Second, budget)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if builder.calls != 3 {
+		t.Fatalf("expected 3 build attempts, got %d", builder.calls)
+	}
+	if budget.TotalTokens() != 30 {
+		t.Fatalf("expected budget to accumulate usage across both failed iterations, got %d total tokens", budget.TotalTokens())
+	}
+}
+
+func TestIterateDockerfileBuild_KeepsKnownGoodDockerfileOnPersistentValidationFailure(t *testing.T) {
+	dockerfilePath := t.TempDir() + "/Dockerfile"
+	originalContent := "FROM golang:1.23\n"
+	if err := os.WriteFile(dockerfilePath, []byte(originalContent), 0644); err != nil {
+		t.Fatalf("writing Dockerfile: %v", err)
+	}
+
+	structurePath := t.TempDir() + "/repo_structure.txt"
+	if err := os.WriteFile(structurePath, []byte("Dockerfile\n"), 0644); err != nil {
+		t.Fatalf("writing repo structure: %v", err)
+	}
+
+	builder := &mockBuilder{
+		name:    "mock",
+		Results: []BuildResult{{Success: false, Stderr: "no such file"}},
+	}
+
+	var analysisCalls int
+	client := &mockAzureOpenAIClient{
+		GetChatCompletionsStreamFunc: func(ctx context.Context, body azopenai.ChatCompletionsStreamOptions, options *azopenai.GetChatCompletionsStreamOptions) (chatCompletionsStream, error) {
+			analysisCalls++
+			// Never a valid Dockerfile: no FROM instruction at all.
+			return &fakeChatCompletionsStream{chunks: []azopenai.ChatCompletions{
+				deltaChunk("<<<DOCKERFILE>>>\nRUN echo not a valid dockerfile\n<<<DOCKERFILE>>>"),
+				usageChunk(10, 5),
+			}}, nil
+		},
+	}
+
+	budget := &TokenBudget{DeploymentID: "o3-mini"}
+	err := iterateDockerfileBuild(client, "o3-mini", builder, dockerfilePath, structurePath, 1, 2*time.Second, budget)
+	if err == nil {
+		t.Fatal("expected error after exhausting iterations, got none")
+	}
+
+	if want := maxValidationRetries + 1; analysisCalls != want {
+		t.Fatalf("expected %d analysis attempts (initial + %d retries), got %d", want, maxValidationRetries, analysisCalls)
+	}
+
+	written, readErr := os.ReadFile(dockerfilePath)
+	if readErr != nil {
+		t.Fatalf("reading Dockerfile: %v", readErr)
+	}
+	if string(written) != originalContent {
+		t.Fatalf("Dockerfile on disk = %q, want unchanged %q", written, originalContent)
+	}
+}
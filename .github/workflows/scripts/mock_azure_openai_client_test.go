@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"io"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+)
+
+// mockAzureOpenAIClient is a hand-rolled AzureOpenAIClient double: each test supplies
+// the Func it needs rather than pulling in a mocking framework.
+type mockAzureOpenAIClient struct {
+	GetChatCompletionsFunc       func(ctx context.Context, body azopenai.ChatCompletionsOptions, options *azopenai.GetChatCompletionsOptions) (azopenai.GetChatCompletionsResponse, error)
+	GetChatCompletionsStreamFunc func(ctx context.Context, body azopenai.ChatCompletionsStreamOptions, options *azopenai.GetChatCompletionsStreamOptions) (chatCompletionsStream, error)
+}
+
+func (m *mockAzureOpenAIClient) GetChatCompletions(ctx context.Context, body azopenai.ChatCompletionsOptions, options *azopenai.GetChatCompletionsOptions) (azopenai.GetChatCompletionsResponse, error) {
+	return m.GetChatCompletionsFunc(ctx, body, options)
+}
+
+func (m *mockAzureOpenAIClient) GetChatCompletionsStream(ctx context.Context, body azopenai.ChatCompletionsStreamOptions, options *azopenai.GetChatCompletionsStreamOptions) (chatCompletionsStream, error) {
+	return m.GetChatCompletionsStreamFunc(ctx, body, options)
+}
+
+// fakeChatCompletionsStream is a hand-rolled chatCompletionsStream double that replays
+// a fixed sequence of chunks, mimicking azopenai's streaming Read/EOF contract.
+type fakeChatCompletionsStream struct {
+	chunks []azopenai.ChatCompletions
+	err    error // returned instead of io.EOF once chunks are exhausted, if set
+	i      int
+}
+
+func (f *fakeChatCompletionsStream) Read() (azopenai.ChatCompletions, error) {
+	if f.i >= len(f.chunks) {
+		if f.err != nil {
+			return azopenai.ChatCompletions{}, f.err
+		}
+		return azopenai.ChatCompletions{}, io.EOF
+	}
+	chunk := f.chunks[f.i]
+	f.i++
+	return chunk, nil
+}
+
+func (f *fakeChatCompletionsStream) Close() error { return nil }
+
+// deltaChunk builds a single streaming chunk carrying one piece of assistant content.
+func deltaChunk(content string) azopenai.ChatCompletions {
+	return azopenai.ChatCompletions{
+		Choices: []azopenai.ChatChoice{
+			{Delta: &azopenai.ChatResponseMessage{Content: to.Ptr(content)}},
+		},
+	}
+}
+
+// usageChunk builds the terminal streaming chunk carrying cumulative token usage.
+func usageChunk(promptTokens, completionTokens int32) azopenai.ChatCompletions {
+	return azopenai.ChatCompletions{
+		Usage: &azopenai.CompletionsUsage{
+			PromptTokens:     to.Ptr(promptTokens),
+			CompletionTokens: to.Ptr(completionTokens),
+		},
+	}
+}
+
+// mockBuilder is a hand-rolled Builder double whose Build method returns the next
+// result from Results on each call, so tests can script a sequence of build outcomes.
+type mockBuilder struct {
+	name    string
+	Results []BuildResult
+	calls   int
+}
+
+func (m *mockBuilder) Name() string { return m.name }
+
+func (m *mockBuilder) Build(ctx context.Context, dockerfilePath, contextDir string) (BuildResult, error) {
+	result := m.Results[m.calls]
+	m.calls++
+	return result, nil
+}
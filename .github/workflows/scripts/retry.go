@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/cenkalti/backoff/v4"
+)
+
+// retryableStatusCodes are the HTTP statuses worth retrying: rate limiting and
+// transient server errors. Anything else (bad request, auth) is terminal.
+var retryableStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// getChatCompletionsWithRetry calls client.GetChatCompletions, retrying transient
+// failures with exponential backoff and jitter. It honors the Retry-After header
+// Azure OpenAI sends on 429 responses, and gives up once aiTimeout has elapsed since
+// the first attempt.
+func getChatCompletionsWithRetry(ctx context.Context, client AzureOpenAIClient, body azopenai.ChatCompletionsOptions, aiTimeout time.Duration) (azopenai.GetChatCompletionsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, aiTimeout)
+	defer cancel()
+
+	expBackoff := newUnboundedBackOff()
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		resp, err := client.GetChatCompletions(ctx, body, nil)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+
+		wait, retryable := nextRetryDelay(err, expBackoff)
+		if !retryable {
+			return azopenai.GetChatCompletionsResponse{}, fmt.Errorf("attempt %d: non-retryable error: %w", attempt, err)
+		}
+
+		fmt.Printf("attempt %d failed (%v); retrying in %s\n", attempt, err, wait)
+
+		select {
+		case <-ctx.Done():
+			return azopenai.GetChatCompletionsResponse{}, fmt.Errorf("giving up after %d attempts, last error: %w", attempt, lastErr)
+		case <-time.After(wait):
+		}
+	}
+}
+
+// getChatCompletionsStreamWithRetry opens a streaming chat completion, retrying
+// transient failures to open the stream with the same backoff and Retry-After
+// handling as getChatCompletionsWithRetry. It does not retry mid-stream: once the
+// stream is open, read errors are the caller's problem.
+func getChatCompletionsStreamWithRetry(ctx context.Context, client AzureOpenAIClient, body azopenai.ChatCompletionsStreamOptions) (chatCompletionsStream, error) {
+	expBackoff := newUnboundedBackOff()
+
+	for attempt := 1; ; attempt++ {
+		stream, err := client.GetChatCompletionsStream(ctx, body, nil)
+		if err == nil {
+			return stream, nil
+		}
+
+		wait, retryable := nextRetryDelay(err, expBackoff)
+		if !retryable {
+			return nil, fmt.Errorf("attempt %d: non-retryable error opening stream: %w", attempt, err)
+		}
+
+		fmt.Printf("attempt %d failed to open stream (%v); retrying in %s\n", attempt, err, wait)
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("giving up after %d attempts, last error: %w", attempt, err)
+		case <-time.After(wait):
+		}
+	}
+}
+
+// newUnboundedBackOff returns an ExponentialBackOff with its own MaxElapsedTime cap
+// disabled. The surrounding ctx (bounded by --ai-timeout) already owns the overall
+// retry deadline; without this, NextBackOff would start returning backoff.Stop after
+// its default 15-minute elapsed time and the caller would busy-spin with zero delay.
+func newUnboundedBackOff() *backoff.ExponentialBackOff {
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = 0
+	return b
+}
+
+// nextRetryDelay decides whether err is worth retrying and, if so, how long to wait
+// before the next attempt: the Retry-After header on a 429, or the next exponential
+// backoff interval otherwise.
+func nextRetryDelay(err error, expBackoff *backoff.ExponentialBackOff) (time.Duration, bool) {
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		if !retryableStatusCodes[respErr.StatusCode] {
+			return 0, false
+		}
+		if respErr.StatusCode == http.StatusTooManyRequests {
+			if wait := retryAfter(respErr); wait > 0 {
+				return wait, true
+			}
+		}
+		return expBackoff.NextBackOff(), true
+	}
+
+	// Non-HTTP errors (network failures, context deadlines) are treated as
+	// transient and retried with backoff.
+	return expBackoff.NextBackOff(), true
+}
+
+// retryAfter extracts the Retry-After header (in seconds) from a 429 response, or
+// returns 0 if it's absent or unparsable.
+func retryAfter(respErr *azcore.ResponseError) time.Duration {
+	if respErr.RawResponse == nil {
+		return 0
+	}
+	header := respErr.RawResponse.Header.Get("Retry-After")
+	if header == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(header)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
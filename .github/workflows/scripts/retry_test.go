@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/cenkalti/backoff/v4"
+)
+
+func respErr(statusCode int, retryAfterHeader string) *azcore.ResponseError {
+	header := http.Header{}
+	if retryAfterHeader != "" {
+		header.Set("Retry-After", retryAfterHeader)
+	}
+	return &azcore.ResponseError{
+		StatusCode:  statusCode,
+		RawResponse: &http.Response{Header: header},
+	}
+}
+
+func TestNextRetryDelay(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantRetryable bool
+		wantWait      time.Duration
+	}{
+		{
+			name:          "429 with Retry-After honors the header",
+			err:           respErr(http.StatusTooManyRequests, "5"),
+			wantRetryable: true,
+			wantWait:      5 * time.Second,
+		},
+		{
+			name:          "429 without Retry-After falls back to backoff",
+			err:           respErr(http.StatusTooManyRequests, ""),
+			wantRetryable: true,
+		},
+		{
+			name:          "500 is retryable via backoff",
+			err:           respErr(http.StatusInternalServerError, ""),
+			wantRetryable: true,
+		},
+		{
+			name:          "502 is retryable via backoff",
+			err:           respErr(http.StatusBadGateway, ""),
+			wantRetryable: true,
+		},
+		{
+			name:          "503 is retryable via backoff",
+			err:           respErr(http.StatusServiceUnavailable, ""),
+			wantRetryable: true,
+		},
+		{
+			name:          "504 is retryable via backoff",
+			err:           respErr(http.StatusGatewayTimeout, ""),
+			wantRetryable: true,
+		},
+		{
+			name:          "400 is terminal",
+			err:           respErr(http.StatusBadRequest, ""),
+			wantRetryable: false,
+		},
+		{
+			name:          "401 is terminal",
+			err:           respErr(http.StatusUnauthorized, ""),
+			wantRetryable: false,
+		},
+		{
+			name:          "404 is terminal",
+			err:           respErr(http.StatusNotFound, ""),
+			wantRetryable: false,
+		},
+		{
+			name:          "non-HTTP error is retried via backoff",
+			err:           errors.New("connection reset"),
+			wantRetryable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, retryable := nextRetryDelay(tt.err, newUnboundedBackOff())
+			if retryable != tt.wantRetryable {
+				t.Fatalf("retryable = %v, want %v", retryable, tt.wantRetryable)
+			}
+			if tt.wantWait != 0 && wait != tt.wantWait {
+				t.Fatalf("wait = %v, want %v", wait, tt.wantWait)
+			}
+			if tt.wantRetryable && wait < 0 {
+				t.Fatalf("wait = %v, want non-negative", wait)
+			}
+		})
+	}
+}
+
+func TestNextRetryDelay_NeverStopsWithinCtxDeadline(t *testing.T) {
+	// A backoff whose own MaxElapsedTime has lapsed reports Stop (a negative wait);
+	// newUnboundedBackOff disables that cap, so nextRetryDelay still reports a
+	// non-negative wait and the surrounding ctx, not the backoff, owns the deadline.
+	expBackoff := backoff.NewExponentialBackOff()
+	expBackoff.MaxElapsedTime = time.Nanosecond
+	time.Sleep(time.Millisecond)
+
+	if got := expBackoff.NextBackOff(); got != backoff.Stop {
+		t.Fatalf("expected the raw backoff to report Stop once elapsed, got %v", got)
+	}
+
+	wait, retryable := nextRetryDelay(respErr(http.StatusInternalServerError, ""), newUnboundedBackOff())
+	if !retryable {
+		t.Fatalf("expected retryable")
+	}
+	if wait < 0 {
+		t.Fatalf("wait = %v, want non-negative (newUnboundedBackOff must not hit MaxElapsedTime)", wait)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{name: "valid seconds", header: "5", want: 5 * time.Second},
+		{name: "missing header", header: "", want: 0},
+		{name: "unparsable header", header: "not-a-number", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := retryAfter(respErr(http.StatusTooManyRequests, tt.header))
+			if got != tt.want {
+				t.Fatalf("retryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfter_NoRawResponse(t *testing.T) {
+	respErr := &azcore.ResponseError{StatusCode: http.StatusTooManyRequests}
+	if got := retryAfter(respErr); got != 0 {
+		t.Fatalf("retryAfter() = %v, want 0", got)
+	}
+}
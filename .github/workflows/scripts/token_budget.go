@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// deploymentPricing gives an approximate per-1K-token USD price for supported
+// deployments, used only to estimate spend against --max-usd; it is not authoritative
+// billing data and should be updated as Azure OpenAI pricing changes.
+var deploymentPricing = map[string]struct {
+	PromptPerThousand     float64
+	CompletionPerThousand float64
+}{
+	"o3-mini": {PromptPerThousand: 0.0011, CompletionPerThousand: 0.0044},
+	"gpt-4o":  {PromptPerThousand: 0.005, CompletionPerThousand: 0.015},
+}
+
+// TokenBudget tracks prompt and completion token usage across the AI calls in a
+// single iterateDockerfileBuild run and enforces an optional ceiling on total tokens
+// or estimated USD cost, so an unattended run can't silently burn through quota.
+type TokenBudget struct {
+	DeploymentID string
+	MaxTokens    int     // 0 means unlimited
+	MaxUSD       float64 // 0 means unlimited
+
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Add records the usage from one chat completion call.
+func (b *TokenBudget) Add(promptTokens, completionTokens int) {
+	b.PromptTokens += promptTokens
+	b.CompletionTokens += completionTokens
+}
+
+// TotalTokens returns the cumulative prompt+completion tokens spent so far.
+func (b *TokenBudget) TotalTokens() int {
+	return b.PromptTokens + b.CompletionTokens
+}
+
+// EstimatedUSD returns a rough cost estimate based on deploymentPricing, or 0 for a
+// deployment this tool doesn't have pricing for.
+func (b *TokenBudget) EstimatedUSD() float64 {
+	price, ok := deploymentPricing[b.DeploymentID]
+	if !ok {
+		return 0
+	}
+	return float64(b.PromptTokens)/1000*price.PromptPerThousand + float64(b.CompletionTokens)/1000*price.CompletionPerThousand
+}
+
+// CheckLimits returns an error describing whichever configured limit was exceeded, or
+// nil if the run is still within budget.
+func (b *TokenBudget) CheckLimits() error {
+	if b.MaxTokens > 0 && b.TotalTokens() > b.MaxTokens {
+		return fmt.Errorf("token budget exceeded: used %d tokens, limit is %d", b.TotalTokens(), b.MaxTokens)
+	}
+	if b.MaxUSD > 0 && b.EstimatedUSD() > b.MaxUSD {
+		return fmt.Errorf("cost budget exceeded: estimated $%.4f, limit is $%.4f", b.EstimatedUSD(), b.MaxUSD)
+	}
+	return nil
+}
+
+// Summary renders a one-line report of cumulative usage for the final iteration report.
+func (b *TokenBudget) Summary() string {
+	return fmt.Sprintf("tokens used: %d prompt + %d completion = %d total (est. $%.4f)",
+		b.PromptTokens, b.CompletionTokens, b.TotalTokens(), b.EstimatedUSD())
+}
+
+// tokenBudgetFromArgs builds a TokenBudget for deploymentID from optional
+// --max-tokens=<int> and --max-usd=<float> flags in args; either or both may be
+// omitted, leaving that dimension unlimited.
+func tokenBudgetFromArgs(args []string, deploymentID string) *TokenBudget {
+	budget := &TokenBudget{DeploymentID: deploymentID}
+	for _, arg := range args {
+		if value, ok := strings.CutPrefix(arg, "--max-tokens="); ok {
+			if n, err := strconv.Atoi(value); err == nil {
+				budget.MaxTokens = n
+			}
+		}
+		if value, ok := strings.CutPrefix(arg, "--max-usd="); ok {
+			if usd, err := strconv.ParseFloat(value, 64); err == nil {
+				budget.MaxUSD = usd
+			}
+		}
+	}
+	return budget
+}